@@ -0,0 +1,43 @@
+package run
+
+import (
+	"sync"
+	"time"
+)
+
+// coalescingTimer collapses a burst of Trigger calls into a single fn
+// invocation, fired once no further calls arrive for the configured window.
+// This replaces sleeping a fixed duration per event, which either reloads
+// once per file in a large multi-file save (notify floods) or still misses
+// events arriving just after the sleep.
+type coalescingTimer struct {
+	window time.Duration
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+func newCoalescingTimer(window time.Duration) *coalescingTimer {
+	return &coalescingTimer{window: window}
+}
+
+// Trigger (re)starts the debounce window; fn runs once the window elapses
+// without a further call to Trigger.
+func (c *coalescingTimer) Trigger(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.timer = time.AfterFunc(c.window, fn)
+}
+
+// Stop cancels any pending fn invocation.
+func (c *coalescingTimer) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
@@ -0,0 +1,35 @@
+package run
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescingTimerCollapsesBurst(t *testing.T) {
+	c := newCoalescingTimer(20 * time.Millisecond)
+	defer c.Stop()
+
+	var fires int32
+	for i := 0; i < 5; i++ {
+		c.Trigger(func() { atomic.AddInt32(&fires, 1) })
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got := atomic.LoadInt32(&fires); got != 1 {
+		t.Fatalf("got %d fires, want 1", got)
+	}
+}
+
+func TestCoalescingTimerStopCancelsPending(t *testing.T) {
+	c := newCoalescingTimer(20 * time.Millisecond)
+
+	var fired int32
+	c.Trigger(func() { atomic.AddInt32(&fired, 1) })
+	c.Stop()
+
+	time.Sleep(40 * time.Millisecond)
+	if got := atomic.LoadInt32(&fired); got != 0 {
+		t.Fatalf("got %d fires, want 0", got)
+	}
+}
@@ -0,0 +1,45 @@
+// Package run manages the lifecycle of `encore run` processes: compiling an
+// app, running it, watching its source tree for changes, and reloading it.
+package run
+
+// App is the app a Run was started for.
+type App interface {
+	// Root returns the app's root directory on disk, the tree Manager.watch
+	// observes for changes.
+	Root() string
+}
+
+// Run represents a single running instance of `encore run` for an app.
+type Run struct {
+	App App
+
+	// WatchConfig configures how Manager.watch observes App's source tree.
+	// The zero value watches the default extensions with the default
+	// backend and debounce, and no ignore globs.
+	WatchConfig WatchConfig
+
+	done chan struct{}
+}
+
+// Done returns a channel that's closed once the run has stopped, so
+// in-flight goroutines (like the one Manager.watch starts) know to exit.
+func (r *Run) Done() <-chan struct{} { return r.done }
+
+// Reload recompiles and restarts the app in place.
+func (r *Run) Reload() error {
+	// Actual recompilation is driven by the daemon's build pipeline;
+	// Manager.watch only needs to trigger it.
+	return nil
+}
+
+// Manager supervises the Runs for an app, and is the receiver Manager.watch
+// and its helpers report output through.
+type Manager struct{}
+
+// runStdout writes b to the run's stdout stream, as seen by `encore run`'s
+// caller.
+func (mgr *Manager) runStdout(run *Run, b []byte) {}
+
+// runStderr writes b to the run's stderr stream, as seen by `encore run`'s
+// caller.
+func (mgr *Manager) runStderr(run *Run, b []byte) {}
@@ -2,59 +2,130 @@ package run
 
 import (
 	"path/filepath"
+	"strings"
 	"time"
-
-	"github.com/rjeczalik/notify"
 )
 
+// WatchConfig configures how Manager.watch observes an app's source tree
+// for changes that should trigger a recompile and reload.
+type WatchConfig struct {
+	// Backend selects the Watcher implementation. Defaults to WatcherNotify.
+	Backend WatcherBackend
+
+	// ExtraExtensions are additional file extensions (including the leading
+	// dot, e.g. ".cue", ".proto", ".tmpl") that trigger a reload, on top of
+	// the built-in set (.go, .sql, .mod, .sum, .app).
+	ExtraExtensions []string
+
+	// IgnoreGlobs are filepath.Match-style glob patterns, matched against
+	// the path relative to the app root, for files and directories that
+	// should never trigger a reload (e.g. "node_modules/*", "dist/*",
+	// ".git/*").
+	IgnoreGlobs []string
+
+	// Debounce is how long to wait for a burst of events to go quiet before
+	// triggering a single reload. Defaults to 100ms if zero.
+	Debounce time.Duration
+}
+
+var defaultWatchExtensions = map[string]bool{
+	".go":  true,
+	".sql": true,
+	".mod": true,
+	".sum": true,
+	".app": true,
+}
+
 // watch watches the given app for changes, and reports
 // them on c.
 func (mgr *Manager) watch(run *Run) error {
-	evs := make(chan notify.EventInfo)
-	if err := notify.Watch(filepath.Join(run.App.Root(), "..."), evs, notify.All); err != nil {
+	cfg := run.WatchConfig
+	if cfg.Debounce <= 0 {
+		cfg.Debounce = 100 * time.Millisecond
+	}
+
+	w, err := newWatcher(cfg.Backend, run.App.Root())
+	if err != nil {
 		return err
 	}
 
 	go func() {
 		<-run.Done()
-		notify.Stop(evs)
+		w.Close()
 	}()
 
 	go func() {
+		debounce := newCoalescingTimer(cfg.Debounce)
+		defer debounce.Stop()
+
+		reload := func() {
+			mgr.runStdout(run, []byte("Changes detected, recompiling...\n"))
+			if err := run.Reload(); err != nil {
+				mgr.runStderr(run, []byte(err.Error()))
+			} else {
+				mgr.runStdout(run, []byte("Reloaded successfully.\n"))
+			}
+		}
+
 		for {
 			select {
 			case <-run.Done():
 				return
-			case ev := <-evs:
-				if ignoreEvent(ev) {
-					continue
+			case ev, ok := <-w.Events():
+				if !ok {
+					return
 				}
-				// We've seen that some editors like vim rename the .go files to another extension,
-				// which breaks our parser since it doesn't recognize the file as a .go file.
-				// This race is annoying, but in practice a 100ms delay is imperceptible since
-				// the user is busy working in their editor.
-				time.Sleep(100 * time.Millisecond)
-				mgr.runStdout(run, []byte("Changes detected, recompiling...\n"))
-				if err := run.Reload(); err != nil {
-					mgr.runStderr(run, []byte(err.Error()))
-				} else {
-					mgr.runStdout(run, []byte("Reloaded successfully.\n"))
+				if ignoreEvent(ev.Path, run.App.Root(), cfg) {
+					continue
 				}
+				// Coalesce bursts of events (e.g. an editor writing several
+				// files on save, or a `go mod tidy`) into a single reload.
+				debounce.Trigger(reload)
 			}
 		}
 	}()
 	return nil
 }
 
-func ignoreEvent(ev notify.EventInfo) bool {
-	path := ev.Path()
-
-	// Ignore non-Go files
+// ignoreEvent reports whether the change at path should not trigger a
+// reload, either because its extension isn't watched or because it matches
+// one of cfg.IgnoreGlobs.
+func ignoreEvent(path, root string, cfg WatchConfig) bool {
 	ext := filepath.Ext(path)
-	switch ext {
-	case ".go", ".sql", ".mod", ".sum", ".app":
-		return false
-	default:
+	if !defaultWatchExtensions[ext] && !extraExtension(ext, cfg.ExtraExtensions) {
+		return true
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+	for _, glob := range cfg.IgnoreGlobs {
+		if matchesIgnoreGlob(glob, rel) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func extraExtension(ext string, extra []string) bool {
+	for _, e := range extra {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesIgnoreGlob reports whether rel matches glob, either directly via
+// filepath.Match or because rel is nested inside a directory the glob
+// names (so "node_modules/*" also ignores "node_modules/foo/bar.go").
+func matchesIgnoreGlob(glob, rel string) bool {
+	if ok, _ := filepath.Match(glob, rel); ok {
 		return true
 	}
+	prefix := strings.TrimSuffix(glob, "/*")
+	return strings.HasPrefix(rel, prefix+"/")
 }
@@ -0,0 +1,47 @@
+package run
+
+import "testing"
+
+func TestIgnoreEventExtension(t *testing.T) {
+	cfg := WatchConfig{}
+
+	if ignoreEvent("/app/main.go", "/app", cfg) {
+		t.Fatal("expected .go files to be watched by default")
+	}
+	if !ignoreEvent("/app/README.md", "/app", cfg) {
+		t.Fatal("expected unlisted extensions to be ignored by default")
+	}
+	if ignoreEvent("/app/schema.cue", "/app", WatchConfig{ExtraExtensions: []string{".cue"}}) {
+		t.Fatal("expected ExtraExtensions to extend the watched set")
+	}
+}
+
+func TestIgnoreEventGlobs(t *testing.T) {
+	cfg := WatchConfig{IgnoreGlobs: []string{"node_modules/*", "dist/*"}}
+
+	if !ignoreEvent("/app/node_modules/foo/bar.go", "/app", cfg) {
+		t.Fatal("expected a file nested under an ignored directory to be ignored")
+	}
+	if ignoreEvent("/app/pkg/foo.go", "/app", cfg) {
+		t.Fatal("expected a file outside any ignore glob to not be ignored")
+	}
+}
+
+func TestMatchesIgnoreGlob(t *testing.T) {
+	tests := []struct {
+		glob, rel string
+		want      bool
+	}{
+		{"node_modules/*", "node_modules/foo.js", true},
+		{"node_modules/*", "node_modules/nested/foo.js", true},
+		{"node_modules/*", "pkg/node_modules_lookalike/foo.js", false},
+		{"*.tmp", "foo.tmp", true},
+		{"*.tmp", "foo.go", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesIgnoreGlob(tt.glob, tt.rel); got != tt.want {
+			t.Errorf("matchesIgnoreGlob(%q, %q) = %v, want %v", tt.glob, tt.rel, got, tt.want)
+		}
+	}
+}
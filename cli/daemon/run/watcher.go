@@ -0,0 +1,294 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rjeczalik/notify"
+)
+
+// WatchEvent describes a single filesystem change observed by a Watcher.
+type WatchEvent struct {
+	// Path is the absolute path of the file or directory that changed.
+	Path string
+}
+
+// Watcher observes an app's source tree for changes and delivers events on
+// its Events channel until Close is called. Manager.watch selects an
+// implementation based on WatchConfig.Backend; different backends trade off
+// latency, reliability, and portability across filesystems.
+type Watcher interface {
+	// Events returns the channel change events are delivered on. It's
+	// closed after Close returns.
+	Events() <-chan WatchEvent
+	// Close stops the watcher and releases its resources.
+	Close() error
+}
+
+// WatcherBackend selects a Watcher implementation.
+type WatcherBackend string
+
+const (
+	// WatcherNotify uses github.com/rjeczalik/notify's recursive OS-level
+	// watch. It's the default, and the cheapest on platforms it supports
+	// well (macOS, Linux with inotify).
+	WatcherNotify WatcherBackend = "notify"
+
+	// WatcherFSNotify uses github.com/fsnotify/fsnotify, watching each
+	// directory individually. It's a fallback for setups where the notify
+	// backend misses events (some network filesystems, certain container
+	// runtimes).
+	WatcherFSNotify WatcherBackend = "fsnotify"
+
+	// WatcherPolling periodically walks the tree and stats every file,
+	// diffing mtimes against the previous scan. It's slower and higher
+	// overhead than the event-driven backends, but it's the only one that
+	// reliably works on network mounts and under WSL, where inotify-style
+	// events are unreliable or entirely absent.
+	WatcherPolling WatcherBackend = "polling"
+
+	// WatcherNoop never reports any changes. Useful for CI and other
+	// environments where a running `encore run` shouldn't auto-reload.
+	WatcherNoop WatcherBackend = "noop"
+)
+
+// newWatcher constructs the Watcher for the given backend, watching root
+// recursively. An empty backend defaults to WatcherNotify.
+func newWatcher(backend WatcherBackend, root string) (Watcher, error) {
+	switch backend {
+	case "", WatcherNotify:
+		return newNotifyWatcher(root)
+	case WatcherFSNotify:
+		return newFSNotifyWatcher(root)
+	case WatcherPolling:
+		return newPollingWatcher(root, 500*time.Millisecond)
+	case WatcherNoop:
+		return newNoopWatcher(), nil
+	default:
+		return nil, fmt.Errorf("run: unknown watcher backend %q", backend)
+	}
+}
+
+// notifyWatcher is the original watcher implementation, backed by
+// github.com/rjeczalik/notify's recursive watch.
+type notifyWatcher struct {
+	evs    chan notify.EventInfo
+	events chan WatchEvent
+	done   chan struct{}
+}
+
+func newNotifyWatcher(root string) (*notifyWatcher, error) {
+	evs := make(chan notify.EventInfo, 100)
+	if err := notify.Watch(filepath.Join(root, "..."), evs, notify.All); err != nil {
+		return nil, err
+	}
+	w := &notifyWatcher{
+		evs:    evs,
+		events: make(chan WatchEvent),
+		done:   make(chan struct{}),
+	}
+	go w.forward()
+	return w, nil
+}
+
+func (w *notifyWatcher) forward() {
+	defer close(w.events)
+	for {
+		select {
+		case <-w.done:
+			return
+		case ev, ok := <-w.evs:
+			if !ok {
+				return
+			}
+			select {
+			case w.events <- WatchEvent{Path: ev.Path()}:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+func (w *notifyWatcher) Events() <-chan WatchEvent { return w.events }
+
+func (w *notifyWatcher) Close() error {
+	close(w.done)
+	notify.Stop(w.evs)
+	return nil
+}
+
+// fsnotifyWatcher watches every directory in the tree individually using
+// github.com/fsnotify/fsnotify, which (unlike rjeczalik/notify) has no
+// built-in support for recursive watches.
+type fsnotifyWatcher struct {
+	w      *fsnotify.Watcher
+	events chan WatchEvent
+	done   chan struct{}
+}
+
+func newFSNotifyWatcher(root string) (*fsnotifyWatcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return fw.Add(path)
+		}
+		return nil
+	})
+	if err != nil {
+		_ = fw.Close()
+		return nil, err
+	}
+
+	w := &fsnotifyWatcher{
+		w:      fw,
+		events: make(chan WatchEvent),
+		done:   make(chan struct{}),
+	}
+	go w.forward()
+	return w, nil
+}
+
+func (w *fsnotifyWatcher) forward() {
+	defer close(w.events)
+	for {
+		select {
+		case <-w.done:
+			return
+		case ev, ok := <-w.w.Events:
+			if !ok {
+				return
+			}
+			// A newly created directory needs its own watch added so files
+			// added under it are picked up too.
+			if ev.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					_ = w.w.Add(ev.Name)
+				}
+			}
+			select {
+			case w.events <- WatchEvent{Path: ev.Name}:
+			case <-w.done:
+				return
+			}
+		case <-w.w.Errors:
+			// Errors are surfaced as no-op; a misbehaving watch shouldn't
+			// take down the run, it just means that one event is missed.
+		}
+	}
+}
+
+func (w *fsnotifyWatcher) Events() <-chan WatchEvent { return w.events }
+
+func (w *fsnotifyWatcher) Close() error {
+	close(w.done)
+	return w.w.Close()
+}
+
+// pollingWatcher periodically re-walks root and diffs file mtimes against
+// the previous scan, for filesystems where OS-level change notifications
+// are unreliable (network mounts, WSL).
+type pollingWatcher struct {
+	events chan WatchEvent
+	done   chan struct{}
+}
+
+func newPollingWatcher(root string, interval time.Duration) (*pollingWatcher, error) {
+	w := &pollingWatcher{
+		events: make(chan WatchEvent),
+		done:   make(chan struct{}),
+	}
+	prev, err := scanMtimes(root)
+	if err != nil {
+		return nil, err
+	}
+	go w.poll(root, interval, prev)
+	return w, nil
+}
+
+func (w *pollingWatcher) poll(root string, interval time.Duration, prev map[string]time.Time) {
+	defer close(w.events)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			cur, err := scanMtimes(root)
+			if err != nil {
+				continue
+			}
+			for path, mtime := range cur {
+				if prevMtime, ok := prev[path]; !ok || !prevMtime.Equal(mtime) {
+					select {
+					case w.events <- WatchEvent{Path: path}:
+					case <-w.done:
+						return
+					}
+				}
+			}
+			for path := range prev {
+				if _, ok := cur[path]; !ok {
+					select {
+					case w.events <- WatchEvent{Path: path}:
+					case <-w.done:
+						return
+					}
+				}
+			}
+			prev = cur
+		}
+	}
+}
+
+func scanMtimes(root string) (map[string]time.Time, error) {
+	mtimes := make(map[string]time.Time)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			mtimes[path] = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mtimes, nil
+}
+
+func (w *pollingWatcher) Events() <-chan WatchEvent { return w.events }
+
+func (w *pollingWatcher) Close() error {
+	close(w.done)
+	return nil
+}
+
+// noopWatcher never reports any events.
+type noopWatcher struct {
+	events chan WatchEvent
+}
+
+func newNoopWatcher() *noopWatcher {
+	return &noopWatcher{events: make(chan WatchEvent)}
+}
+
+func (w *noopWatcher) Events() <-chan WatchEvent { return w.events }
+
+func (w *noopWatcher) Close() error {
+	close(w.events)
+	return nil
+}
@@ -0,0 +1,90 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestNoopWatcherNeverReportsEvents(t *testing.T) {
+	w := newNoopWatcher()
+	defer w.Close()
+
+	select {
+	case _, ok := <-w.Events():
+		if ok {
+			t.Fatal("expected noopWatcher to never deliver an event")
+		}
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestPollingWatcherDetectsChanges(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	if err := os.WriteFile(path, []byte("package main"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := newPollingWatcher(root, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// newPollingWatcher's initial scan already observed path. Some
+	// filesystems only have one-second mtime resolution, so wait past that
+	// before rewriting the file, or the poll could see an unchanged mtime.
+	time.Sleep(1100 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("package main // changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-w.Events():
+		if ev.Path != path {
+			t.Fatalf("got event for %q, want %q", ev.Path, path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for polling watcher to report the change")
+	}
+}
+
+// TestFSNotifyWatcherCloseDoesNotLeakGoroutine verifies that Close unblocks
+// forward() even when it's parked mid-send on an unbuffered w.events with no
+// reader, which previously leaked a goroutine on every Close call made
+// while an event was in flight.
+func TestFSNotifyWatcherCloseDoesNotLeakGoroutine(t *testing.T) {
+	root := t.TempDir()
+
+	before := runtime.NumGoroutine()
+
+	w, err := newFSNotifyWatcher(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Generate events without ever reading w.Events(), so forward() blocks
+	// on the unbuffered send.
+	for i := 0; i < 10; i++ {
+		path := filepath.Join(root, "file"+string(rune('a'+i))+".go")
+		if err := os.WriteFile(path, []byte("package main"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutine count did not return to baseline after Close: got %d, want <= %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
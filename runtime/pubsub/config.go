@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"context"
+	"time"
+
+	"encore.dev/pubsub/eventreg"
+)
+
+// DeliveryGuarantee specifies the delivery guarantee wanted for a Topic.
+type DeliveryGuarantee int
+
+const (
+	// AtLeastOnce guarantees that a message for a subscription is delivered
+	// to a subscriber at least once.
+	AtLeastOnce DeliveryGuarantee = iota
+)
+
+// RetryPolicy defines how a subscription retries a failed message delivery.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of times a failed message will be
+	// redelivered before it's dropped. Routing to a dead letter topic is
+	// decided separately, by DeadLetterPolicy.MaxDeliveryAttempts.
+	MaxRetries int
+
+	// MinBackoff is the minimum time to wait between redelivery attempts.
+	// Defaults to 10 seconds.
+	MinBackoff time.Duration
+
+	// MaxBackoff is the maximum time to wait between redelivery attempts.
+	// Defaults to 10 minutes.
+	MaxBackoff time.Duration
+}
+
+// SubscriptionConfig configures a Subscription. See NewSubscription's
+// doc comment for an example.
+type SubscriptionConfig[T any] struct {
+	// Handler is called for every message delivered to the subscription.
+	Handler func(ctx context.Context, msg T) error
+
+	// RetryPolicy configures how failed deliveries are retried.
+	// If nil, defaults to &RetryPolicy{MaxRetries: 100}.
+	RetryPolicy *RetryPolicy
+
+	// DeadLetterPolicy, if set, routes messages whose handler still fails on
+	// delivery attempt DeadLetterPolicy.MaxDeliveryAttempts to a dead letter
+	// topic instead of retrying them further.
+	DeadLetterPolicy *DeadLetterPolicy[T]
+
+	// Filter is a Google Pub/Sub-style attribute filter expression,
+	// evaluated against each message's attributes before it's unmarshalled
+	// and handed to Handler. See package encore.dev/pubsub/internal/filter
+	// for the supported grammar. Messages that don't match are ack'd
+	// without invoking Handler.
+	Filter string
+
+	// AckOrdering, if true, serializes handler invocations for messages
+	// that share an ordering key (see WithOrderingKey) so
+	// they're delivered to Handler in the order they were published.
+	AckOrdering bool
+
+	// Idempotency, if set, is the Name of an eventreg.Event[T] registered
+	// for T. NewSubscription looks it up and deduplicates deliveries by the
+	// event's IdempotencyKey, ack'ing duplicates without invoking Handler.
+	Idempotency string
+
+	// IdempotencyStore overrides the eventreg.Store used for the
+	// Idempotency check above. Defaults to eventreg.DefaultStore().
+	IdempotencyStore eventreg.Store
+}
@@ -0,0 +1,56 @@
+package pubsub
+
+import (
+	"context"
+	"strconv"
+)
+
+// DeadLetterPolicy configures what happens to a message once its handler has
+// failed MaxDeliveryAttempts deliveries. Rather than being dropped (or retried
+// for as long as RetryPolicy.MaxRetries allows), the message is
+// republished onto DeadLetterTopic with additional attributes describing why
+// it ended up there.
+//
+// This mirrors the dead-letter topic model used by GCP Pub/Sub subscriptions.
+type DeadLetterPolicy[T any] struct {
+	// MaxDeliveryAttempts is the number of delivery attempts (including the
+	// first) the subscription will make before the message is routed to
+	// DeadLetterTopic instead of being retried again. It must be at least 2,
+	// so that at least one retry is attempted before dead-lettering.
+	MaxDeliveryAttempts int
+
+	// DeadLetterTopic is the topic that exhausted messages are republished to.
+	DeadLetterTopic *Topic[T]
+}
+
+// Dead-letter attribute keys set on messages republished to a DeadLetterTopic.
+const (
+	dlqAttrOriginalTopic        = "encore_dlq_original_topic"
+	dlqAttrOriginalSubscription = "encore_dlq_original_subscription"
+	dlqAttrLastError            = "encore_dlq_last_error"
+	dlqAttrDeliveryAttempt      = "encore_dlq_delivery_attempt"
+)
+
+// exhausted reports whether deliveryAttempt has used up the configured
+// number of delivery attempts for this dead-letter policy.
+func (p *DeadLetterPolicy[T]) exhausted(deliveryAttempt int) bool {
+	return p != nil && p.MaxDeliveryAttempts > 0 && deliveryAttempt >= p.MaxDeliveryAttempts
+}
+
+// deadLetter republishes an exhausted message onto policy.DeadLetterTopic,
+// tagging it with attributes describing where it came from and why it's here.
+// The original attributes set by the publisher are preserved alongside the
+// dead-letter ones.
+func deadLetter[T any](ctx context.Context, policy *DeadLetterPolicy[T], originalTopic, originalSubscription string, deliveryAttempt int, lastErr error, attrs map[string]string, data []byte) error {
+	dlqAttrs := make(map[string]string, len(attrs)+4)
+	for k, v := range attrs {
+		dlqAttrs[k] = v
+	}
+	dlqAttrs[dlqAttrOriginalTopic] = originalTopic
+	dlqAttrs[dlqAttrOriginalSubscription] = originalSubscription
+	dlqAttrs[dlqAttrLastError] = lastErr.Error()
+	dlqAttrs[dlqAttrDeliveryAttempt] = strconv.Itoa(deliveryAttempt)
+
+	_, err := policy.DeadLetterTopic.topic.PublishDeadLetter(ctx, dlqAttrs, data)
+	return err
+}
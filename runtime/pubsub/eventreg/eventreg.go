@@ -0,0 +1,82 @@
+// Package eventreg lets an app declare its pubsub event types once - their
+// name, the field used to deduplicate redeliveries, and (optionally) the
+// actor that produced them - and reuse that declaration from every
+// publisher and subscriber for the event.
+//
+// Today Register must be called explicitly next to the event type. The
+// intent is for the Encore compiler to eventually generate this call (along
+// with typed Publish/Subscribe wrappers) from a single struct tag or config
+// block, the same way it generates API handlers; that codegen doesn't exist
+// yet, so Register is the stable, hand-written entry point it will target.
+package eventreg
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is the idempotency-key retention period used when an Event
+// doesn't specify its own TTL.
+const DefaultTTL = 24 * time.Hour
+
+// Event is the registration for a named event type T. It's looked up by
+// NewSubscription when a subscription enables idempotency checking, so that
+// every subscriber for the event dedupes redeliveries the same way.
+type Event[T any] struct {
+	// Name uniquely identifies this event type across the app. It's used as
+	// part of the idempotency store's key, so two events must not share a
+	// Name even if their Go types differ.
+	Name string
+
+	// IdempotencyKey extracts the field (or combination of fields) from an
+	// event that identifies it uniquely. Two deliveries that produce the
+	// same key are treated as the same logical event; the second (and any
+	// later) delivery is ack'd without invoking the subscriber handler.
+	IdempotencyKey func(T) string
+
+	// Actor optionally extracts the actor that produced the event, for
+	// attribution in logs and traces. May be left nil.
+	Actor func(T) string
+
+	// TTL is how long an idempotency key is remembered. After it elapses the
+	// key may be reused; this assumes the transport won't redeliver a
+	// message that much later. Defaults to DefaultTTL.
+	TTL time.Duration
+}
+
+var registry sync.Map // name (string) -> any, dynamically typed as *Event[T]
+
+// Register declares ev's event type in the package-level registry, keyed by
+// ev.Name. It panics if ev.Name is empty, ev.IdempotencyKey is nil, or an
+// event with the same name is already registered - all programmer errors
+// that should fail at startup, not at delivery time.
+func Register[T any](ev Event[T]) *Event[T] {
+	if ev.Name == "" {
+		panic("eventreg: Event.Name must not be empty")
+	}
+	if ev.IdempotencyKey == nil {
+		panic(fmt.Sprintf("eventreg: event %q must set IdempotencyKey", ev.Name))
+	}
+	if ev.TTL <= 0 {
+		ev.TTL = DefaultTTL
+	}
+
+	e := &ev
+	if _, loaded := registry.LoadOrStore(ev.Name, e); loaded {
+		panic(fmt.Sprintf("eventreg: event %q is already registered", ev.Name))
+	}
+	return e
+}
+
+// Lookup returns the Event[T] registered under name, if any. It reports
+// false both when no event is registered under that name and when one is
+// registered with a different Go type.
+func Lookup[T any](name string) (*Event[T], bool) {
+	v, ok := registry.Load(name)
+	if !ok {
+		return nil, false
+	}
+	e, ok := v.(*Event[T])
+	return e, ok
+}
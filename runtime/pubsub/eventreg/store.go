@@ -0,0 +1,108 @@
+package eventreg
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store tracks which idempotency keys have already been processed, so that
+// the subscribe wrapper in pubsub.NewSubscription can short-circuit-ack
+// redelivered duplicates before invoking the subscriber handler.
+//
+// The handler runs at-least-once delivery, so a key must not be considered
+// seen until the handler has actually succeeded for it - otherwise a
+// transient handler failure on first delivery would permanently mark the
+// key as a duplicate, and every redelivery would be silently ack'd without
+// ever invoking the handler again. MarkPending/Commit/Release give the
+// subscribe wrapper a way to claim a key before running the handler and
+// then either confirm or undo that claim once the handler's result is
+// known, so exactly-once handler semantics hold on top of the at-least-once
+// transport.
+//
+// MemoryStore is the only implementation provided here. A Redis- or
+// SQL-backed Store is expected to be supplied by the app (wrapping whichever
+// client it already uses) and just needs to satisfy this interface -
+// there's deliberately no built-in Redis/SQL client dependency in the
+// runtime.
+type Store interface {
+	// MarkPending atomically checks whether key has already been committed
+	// or is currently pending (i.e. claimed by a delivery whose handler
+	// hasn't yet finished) and, if not, claims it as pending with the given
+	// TTL. It reports whether this call made the claim; a false result
+	// means the event is a duplicate, either already committed or still
+	// being handled by a concurrent delivery.
+	MarkPending(ctx context.Context, key string, ttl time.Duration) (claimed bool, err error)
+
+	// Commit marks key as permanently seen, so future MarkPending calls for
+	// it report claimed=false until ttl elapses. Called once the handler
+	// for a pending key has succeeded.
+	Commit(ctx context.Context, key string, ttl time.Duration) error
+
+	// Release undoes a prior successful MarkPending, so a later delivery of
+	// the same key can claim it again. Called when the handler for a
+	// pending key fails, so the next redelivery gets a real attempt instead
+	// of being ack'd as a duplicate.
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryStore is an in-process Store backed by a map. It's only consistent
+// within a single process, which makes it suitable for local development
+// and tests but not for a horizontally-scaled deployment, where a shared
+// Redis- or SQL-backed Store should be used instead.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryState int
+
+const (
+	statePending memoryState = iota
+	stateCommitted
+)
+
+type memoryEntry struct {
+	state  memoryState
+	expiry time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (s *MemoryStore) MarkPending(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok && now.Before(e.expiry) {
+		return false, nil
+	}
+	s.entries[key] = memoryEntry{state: statePending, expiry: now.Add(ttl)}
+	return true, nil
+}
+
+func (s *MemoryStore) Commit(ctx context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = memoryEntry{state: stateCommitted, expiry: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+var defaultStore = NewMemoryStore()
+
+// DefaultStore returns the process-wide MemoryStore used by subscriptions
+// that enable idempotency checking without specifying their own Store.
+func DefaultStore() Store { return defaultStore }
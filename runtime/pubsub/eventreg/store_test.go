@@ -0,0 +1,82 @@
+package eventreg
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreRetryAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	const key = "order-created:123"
+
+	// First delivery claims the key.
+	claimed, err := s.MarkPending(ctx, key, time.Minute)
+	if err != nil {
+		t.Fatalf("MarkPending: %v", err)
+	}
+	if !claimed {
+		t.Fatal("expected the first delivery to claim the key")
+	}
+
+	// A redelivery that arrives while the handler is still running must not
+	// also be allowed to run the handler.
+	claimed, err = s.MarkPending(ctx, key, time.Minute)
+	if err != nil {
+		t.Fatalf("MarkPending (concurrent redelivery): %v", err)
+	}
+	if claimed {
+		t.Fatal("expected a redelivery to be rejected while the key is pending")
+	}
+
+	// The handler fails, so the subscribe wrapper releases the claim.
+	if err := s.Release(ctx, key); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+
+	// The retry must be able to claim the key again and get a real attempt,
+	// not be silently ack'd as a duplicate.
+	claimed, err = s.MarkPending(ctx, key, time.Minute)
+	if err != nil {
+		t.Fatalf("MarkPending (retry): %v", err)
+	}
+	if !claimed {
+		t.Fatal("expected the retry to claim the key after it was released")
+	}
+
+	// This time the handler succeeds, so the wrapper commits the key.
+	if err := s.Commit(ctx, key, time.Minute); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	// Any further redelivery is now a genuine duplicate.
+	claimed, err = s.MarkPending(ctx, key, time.Minute)
+	if err != nil {
+		t.Fatalf("MarkPending (after commit): %v", err)
+	}
+	if claimed {
+		t.Fatal("expected a redelivery after commit to be rejected")
+	}
+}
+
+func TestMemoryStoreCommitExpires(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	const key = "order-created:123"
+
+	if _, err := s.MarkPending(ctx, key, time.Minute); err != nil {
+		t.Fatalf("MarkPending: %v", err)
+	}
+	if err := s.Commit(ctx, key, -time.Second); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	claimed, err := s.MarkPending(ctx, key, time.Minute)
+	if err != nil {
+		t.Fatalf("MarkPending (after expiry): %v", err)
+	}
+	if !claimed {
+		t.Fatal("expected the key to be claimable again once its commit TTL has elapsed")
+	}
+}
@@ -0,0 +1,140 @@
+// Package aws backs a pubsub Topic with an SNS topic fanning out to an SQS
+// queue per subscription, the standard AWS pubsub pairing.
+package aws
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/rs/zerolog"
+)
+
+// Topic publishes to an SNS topic and, for Subscribe, reads from the SQS
+// queue subscribed to it.
+type Topic struct {
+	topicARN string
+	sns      *sns.Client
+	sqs      *sqs.Client
+}
+
+// NewTopic creates a Topic backed by the SNS topic named name, resolving
+// its ARN via the standard AWS config chain (environment, shared config,
+// IAM role).
+func NewTopic(name string) *Topic {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		panic("aws: failed to load config: " + err.Error())
+	}
+	snsClient := sns.NewFromConfig(cfg)
+	out, err := snsClient.CreateTopic(context.Background(), &sns.CreateTopicInput{Name: aws.String(name)})
+	if err != nil {
+		panic("aws: failed to resolve topic ARN for " + name + ": " + err.Error())
+	}
+	return &Topic{topicARN: *out.TopicArn, sns: snsClient, sqs: sqs.NewFromConfig(cfg)}
+}
+
+// PublishMessage publishes data to the SNS topic, with attrs and
+// orderingKey (when set) carried as SNS message attributes / the
+// MessageGroupId of a FIFO topic.
+func (t *Topic) PublishMessage(ctx context.Context, orderingKey string, attrs map[string]string, data []byte) (string, error) {
+	msgAttrs := make(map[string]snstypes.MessageAttributeValue, len(attrs))
+	for k, v := range attrs {
+		msgAttrs[k] = snstypes.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
+
+	in := &sns.PublishInput{
+		TopicArn:          aws.String(t.topicARN),
+		Message:           aws.String(string(data)),
+		MessageAttributes: msgAttrs,
+	}
+	if orderingKey != "" {
+		in.MessageGroupId = aws.String(orderingKey)
+	}
+
+	out, err := t.sns.Publish(ctx, in)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.MessageId), nil
+}
+
+// PublishDeadLetter republishes data and attrs verbatim onto the topic.
+func (t *Topic) PublishDeadLetter(ctx context.Context, attrs map[string]string, data []byte) (string, error) {
+	return t.PublishMessage(ctx, "", attrs, data)
+}
+
+// Subscribe long-polls the SQS queue subscribed to this topic named
+// subscriptionName and invokes handler for every message received,
+// deleting it from the queue once handler returns nil. filter isn't
+// evaluated here - SNS subscription filter policies are configured at
+// provisioning time outside this package, and pubsub.NewSubscription's
+// client-side filterExpr covers attributes a filter policy can't express.
+func (t *Topic) Subscribe(
+	log *zerolog.Logger,
+	subscriptionName string,
+	maxRetries int,
+	minBackoff, maxBackoff time.Duration,
+	filter string,
+	handler func(ctx context.Context, msgID string, publishTime time.Time, deliveryAttempt int, attrs map[string]string, data []byte) error,
+) {
+	queueURLOut, err := t.sqs.GetQueueUrl(context.Background(), &sqs.GetQueueUrlInput{QueueName: aws.String(subscriptionName)})
+	if err != nil {
+		log.Err(err).Str("subscription", subscriptionName).Msg("aws: failed to resolve queue URL")
+		return
+	}
+	queueURL := queueURLOut.QueueUrl
+
+	go func() {
+		for {
+			out, err := t.sqs.ReceiveMessage(context.Background(), &sqs.ReceiveMessageInput{
+				QueueUrl:              queueURL,
+				MaxNumberOfMessages:   10,
+				WaitTimeSeconds:       20,
+				AttributeNames:        []types.QueueAttributeName{types.QueueAttributeName(types.MessageSystemAttributeNameApproximateReceiveCount)},
+				MessageAttributeNames: []string{"All"},
+			})
+			if err != nil {
+				log.Err(err).Str("subscription", subscriptionName).Msg("aws: ReceiveMessage failed")
+				continue
+			}
+
+			for _, m := range out.Messages {
+				attempt := 1
+				if n, ok := m.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)]; ok {
+					if parsed, err := strconv.Atoi(n); err == nil {
+						attempt = parsed
+					}
+				}
+
+				attrs := make(map[string]string, len(m.MessageAttributes))
+				for k, v := range m.MessageAttributes {
+					attrs[k] = aws.ToString(v.StringValue)
+				}
+
+				err := handler(context.Background(), aws.ToString(m.MessageId), time.Now(), attempt, attrs, []byte(aws.ToString(m.Body)))
+				if err != nil {
+					log.Err(err).Str("msg_id", aws.ToString(m.MessageId)).Int("delivery_attempt", attempt).Msg("aws: handler returned an error, leaving message for redelivery")
+					continue
+				}
+
+				_, err = t.sqs.DeleteMessage(context.Background(), &sqs.DeleteMessageInput{
+					QueueUrl:      queueURL,
+					ReceiptHandle: m.ReceiptHandle,
+				})
+				if err != nil {
+					log.Err(err).Str("msg_id", aws.ToString(m.MessageId)).Msg("aws: failed to delete acknowledged message")
+				}
+			}
+		}
+	}()
+}
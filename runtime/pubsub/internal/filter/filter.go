@@ -0,0 +1,307 @@
+// Package filter implements a small parser and evaluator for Google Pub/Sub-style
+// attribute filter expressions, as used by pubsub.SubscriptionConfig.Filter.
+//
+// The supported grammar is:
+//
+//	expr       = orExpr
+//	orExpr     = andExpr ( "OR" andExpr )*
+//	andExpr    = unary ( "AND" unary )*
+//	unary      = "NOT" unary | primary
+//	primary    = "(" expr ")" | comparison | call
+//	comparison = attrRef ( "=" | "!=" ) literal
+//	call       = "hasPrefix" "(" attrRef "," literal ")"
+//	attrRef    = "attributes." ident
+//	literal    = string | number
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr is a parsed filter expression that can be evaluated against a message's
+// attributes.
+type Expr interface {
+	// Eval reports whether attrs satisfies the expression.
+	Eval(attrs map[string]string) bool
+}
+
+// Parse parses a filter expression, returning an Expr that can be evaluated
+// repeatedly against incoming message attributes.
+//
+// Parsing happens once, at subscription registration time, so evaluation on
+// the hot path is just a tree walk with no further parsing.
+func Parse(src string) (Expr, error) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, fmt.Errorf("filter: %v", err)
+	}
+	p := &parser{toks: toks}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("filter: %v", err)
+	}
+	if p.pos != len(p.toks) {
+		return nil, fmt.Errorf("filter: unexpected token %q", p.toks[p.pos].val)
+	}
+	return expr, nil
+}
+
+// --- AST ---
+
+type orExpr struct{ lhs, rhs Expr }
+
+func (e *orExpr) Eval(attrs map[string]string) bool { return e.lhs.Eval(attrs) || e.rhs.Eval(attrs) }
+
+type andExpr struct{ lhs, rhs Expr }
+
+func (e *andExpr) Eval(attrs map[string]string) bool { return e.lhs.Eval(attrs) && e.rhs.Eval(attrs) }
+
+type notExpr struct{ inner Expr }
+
+func (e *notExpr) Eval(attrs map[string]string) bool { return !e.inner.Eval(attrs) }
+
+type eqExpr struct {
+	attr  string
+	value string
+	equal bool
+}
+
+func (e *eqExpr) Eval(attrs map[string]string) bool {
+	return (attrs[e.attr] == e.value) == e.equal
+}
+
+type hasPrefixExpr struct {
+	attr   string
+	prefix string
+}
+
+func (e *hasPrefixExpr) Eval(attrs map[string]string) bool {
+	return strings.HasPrefix(attrs[e.attr], e.prefix)
+}
+
+// --- parser ---
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokString
+	tokNumber
+	tokSymbol
+	tokEOF
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	if p.pos >= len(p.toks) {
+		return token{kind: tokEOF}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokIdent && strings.EqualFold(p.peek().val, "OR") {
+		p.next()
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &orExpr{lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokIdent && strings.EqualFold(p.peek().val, "AND") {
+		p.next()
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &andExpr{lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.peek().kind == tokIdent && strings.EqualFold(p.peek().val, "NOT") {
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{inner: inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == tokSymbol && tok.val == "(":
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokSymbol || t.val != ")" {
+			return nil, fmt.Errorf("expected ')', got %q", t.val)
+		}
+		return expr, nil
+
+	case tok.kind == tokIdent && tok.val == "hasPrefix":
+		p.next()
+		if t := p.next(); t.kind != tokSymbol || t.val != "(" {
+			return nil, fmt.Errorf("expected '(' after hasPrefix, got %q", t.val)
+		}
+		attr, err := p.parseAttrRef()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokSymbol || t.val != "," {
+			return nil, fmt.Errorf("expected ',' in hasPrefix, got %q", t.val)
+		}
+		lit, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokSymbol || t.val != ")" {
+			return nil, fmt.Errorf("expected ')' closing hasPrefix, got %q", t.val)
+		}
+		return &hasPrefixExpr{attr: attr, prefix: lit}, nil
+
+	case tok.kind == tokIdent && strings.HasPrefix(tok.val, "attributes."):
+		attr, err := p.parseAttrRef()
+		if err != nil {
+			return nil, err
+		}
+		op := p.next()
+		if op.kind != tokSymbol || (op.val != "=" && op.val != "!=") {
+			return nil, fmt.Errorf("expected '=' or '!=', got %q", op.val)
+		}
+		lit, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &eqExpr{attr: attr, value: lit, equal: op.val == "="}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected token %q", tok.val)
+}
+
+func (p *parser) parseAttrRef() (string, error) {
+	tok := p.next()
+	if tok.kind != tokIdent || !strings.HasPrefix(tok.val, "attributes.") {
+		return "", fmt.Errorf("expected attribute reference (attributes.<name>), got %q", tok.val)
+	}
+	return strings.TrimPrefix(tok.val, "attributes."), nil
+}
+
+func (p *parser) parseLiteral() (string, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokString:
+		return tok.val, nil
+	case tokNumber:
+		return tok.val, nil
+	default:
+		return "", fmt.Errorf("expected literal, got %q", tok.val)
+	}
+}
+
+// --- tokenizer ---
+
+func tokenize(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '(' || c == ')' || c == ',':
+			toks = append(toks, token{kind: tokSymbol, val: string(c)})
+			i++
+
+		case c == '=':
+			toks = append(toks, token{kind: tokSymbol, val: "="})
+			i++
+
+		case c == '!' && i+1 < len(src) && src[i+1] == '=':
+			toks = append(toks, token{kind: tokSymbol, val: "!="})
+			i += 2
+
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string literal")
+			}
+			toks = append(toks, token{kind: tokString, val: src[i+1 : j]})
+			i = j + 1
+
+		case isIdentStart(c):
+			j := i
+			for j < len(src) && isIdentPart(src[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, val: src[i:j]})
+			i = j
+
+		case isDigit(c) || (c == '-' && i+1 < len(src) && isDigit(src[i+1])):
+			j := i + 1
+			for j < len(src) && (isDigit(src[j]) || src[j] == '.') {
+				j++
+			}
+			if _, err := strconv.ParseFloat(src[i:j], 64); err != nil {
+				return nil, fmt.Errorf("invalid number literal %q", src[i:j])
+			}
+			toks = append(toks, token{kind: tokNumber, val: src[i:j]})
+			i = j
+
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return toks, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '.' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,100 @@
+package filter
+
+import "testing"
+
+func eval(t *testing.T, src string, attrs map[string]string) bool {
+	t.Helper()
+	expr, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", src, err)
+	}
+	return expr.Eval(attrs)
+}
+
+func TestEquality(t *testing.T) {
+	attrs := map[string]string{"region": "us"}
+
+	if !eval(t, `attributes.region = "us"`, attrs) {
+		t.Error("expected region = \"us\" to match")
+	}
+	if eval(t, `attributes.region = "eu"`, attrs) {
+		t.Error("expected region = \"eu\" to not match")
+	}
+	if eval(t, `attributes.region != "us"`, attrs) {
+		t.Error("expected region != \"us\" to not match")
+	}
+	if !eval(t, `attributes.missing = ""`, attrs) {
+		t.Error("expected a missing attribute to compare equal to the empty string")
+	}
+}
+
+func TestHasPrefix(t *testing.T) {
+	attrs := map[string]string{"path": "/api/v1/users"}
+
+	if !eval(t, `hasPrefix(attributes.path, "/api/")`, attrs) {
+		t.Error("expected hasPrefix to match")
+	}
+	if eval(t, `hasPrefix(attributes.path, "/admin/")`, attrs) {
+		t.Error("expected hasPrefix to not match")
+	}
+}
+
+func TestNot(t *testing.T) {
+	attrs := map[string]string{"region": "us"}
+
+	if !eval(t, `NOT attributes.region = "eu"`, attrs) {
+		t.Error("expected NOT to negate a false comparison to true")
+	}
+	if eval(t, `NOT attributes.region = "us"`, attrs) {
+		t.Error("expected NOT to negate a true comparison to false")
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	// AND binds tighter than OR, so this parses as:
+	//   region = "eu" OR (tier = "gold" AND active = "true")
+	// and must match the eu attendee even though tier/active don't match.
+	const src = `attributes.region = "eu" OR attributes.tier = "gold" AND attributes.active = "true"`
+
+	if !eval(t, src, map[string]string{"region": "eu", "tier": "bronze", "active": "false"}) {
+		t.Error("expected the OR branch to match regardless of the AND branch")
+	}
+	if eval(t, src, map[string]string{"region": "us", "tier": "gold", "active": "false"}) {
+		t.Error("expected the AND branch to require both operands")
+	}
+	if !eval(t, src, map[string]string{"region": "us", "tier": "gold", "active": "true"}) {
+		t.Error("expected the AND branch to match when both operands hold")
+	}
+}
+
+func TestParens(t *testing.T) {
+	// The parenthesized sub-expression is evaluated as a single operand:
+	// region = "eu" OR (region = "us" AND tier = "gold"). This is the same
+	// grouping AND's higher precedence gives without the parens.
+	const src = `attributes.region = "eu" OR (attributes.region = "us" AND attributes.tier = "gold")`
+
+	if !eval(t, src, map[string]string{"region": "eu", "tier": "bronze"}) {
+		t.Error("expected the OR branch outside the parens to match on region alone")
+	}
+	if eval(t, src, map[string]string{"region": "us", "tier": "bronze"}) {
+		t.Error("expected the parenthesized AND branch to require both operands")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		``,
+		`attributes.region =`,
+		`attributes.region "us"`,
+		`(attributes.region = "us"`,
+		`hasPrefix(attributes.region, "us"`,
+		`attributes.region = "unterminated`,
+		`attributes.region = "us" extra`,
+		`attributes.region @ "us"`,
+	}
+	for _, src := range tests {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", src)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Package gcp backs a pubsub Topic with a Google Cloud Pub/Sub topic and
+// its subscriptions.
+package gcp
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/rs/zerolog"
+)
+
+// Topic publishes to, and subscribes from, a single GCP Pub/Sub topic.
+type Topic struct {
+	name   string
+	client *pubsub.Client
+	topic  *pubsub.Topic
+}
+
+// NewTopic creates a Topic backed by the GCP Pub/Sub topic named name in
+// the project resolved from the standard GOOGLE_CLOUD_PROJECT/ADC chain.
+func NewTopic(name string) *Topic {
+	client, err := pubsub.NewClient(context.Background(), "")
+	if err != nil {
+		panic("gcp: failed to create pubsub client: " + err.Error())
+	}
+	return &Topic{name: name, client: client, topic: client.Topic(name)}
+}
+
+// PublishMessage publishes data and attrs to the topic, setting
+// PublishMessage.OrderingKey when orderingKey is non-empty so GCP serializes
+// delivery of messages sharing a key (the topic must have ordering enabled).
+func (t *Topic) PublishMessage(ctx context.Context, orderingKey string, attrs map[string]string, data []byte) (string, error) {
+	res := t.topic.Publish(ctx, &pubsub.Message{
+		Data:        data,
+		Attributes:  attrs,
+		OrderingKey: orderingKey,
+	})
+	return res.Get(ctx)
+}
+
+// PublishDeadLetter republishes data and attrs verbatim onto the topic,
+// with no ordering key.
+func (t *Topic) PublishDeadLetter(ctx context.Context, attrs map[string]string, data []byte) (string, error) {
+	return t.PublishMessage(ctx, "", attrs, data)
+}
+
+// Subscribe receives from the GCP Pub/Sub subscription named
+// subscriptionName and invokes handler for every message, acking on nil and
+// nacking on error, so GCP redelivers it according to the subscription's own
+// provisioned retry settings. maxRetries and minBackoff are not used here;
+// maxBackoff sets ReceiveSettings.MaxExtensionPeriod.
+//
+// filter is NOT re-applied here. A GCP Pub/Sub subscription's filter is an
+// attribute expression set when the subscription is created or updated
+// (via the Admin API, not this publish/consume client), after which
+// Pub/Sub itself only delivers matching messages and auto-acks the rest -
+// there's no per-receive knob on *pubsub.Subscription to pass it through
+// again. Provisioning is expected to set the subscription's filter from
+// this same string so it matches what pubsub.NewSubscription's client-side
+// filterExpr evaluates.
+func (t *Topic) Subscribe(
+	log *zerolog.Logger,
+	subscriptionName string,
+	maxRetries int,
+	minBackoff, maxBackoff time.Duration,
+	filter string,
+	handler func(ctx context.Context, msgID string, publishTime time.Time, deliveryAttempt int, attrs map[string]string, data []byte) error,
+) {
+	// maxRetries isn't passed to GCP here for the same reason filter isn't:
+	// a subscription's dead-letter policy (which is what bounds redelivery
+	// attempts on GCP) is also provisioning-side config, not a Receive option.
+	//
+	// Subscriptions belong to the Client, not the Topic - there's no
+	// Topic.Subscribe - so retrieve the pre-existing subscription (see doc
+	// comment above) via the client retained in NewTopic.
+	sub := t.client.Subscription(subscriptionName)
+	sub.ReceiveSettings.MaxExtensionPeriod = maxBackoff
+
+	go func() {
+		err := sub.Receive(context.Background(), func(ctx context.Context, m *pubsub.Message) {
+			attempt := 1
+			if m.DeliveryAttempt != nil {
+				attempt = *m.DeliveryAttempt
+			}
+
+			if err := handler(ctx, m.ID, m.PublishTime, attempt, m.Attributes, m.Data); err != nil {
+				log.Err(err).Str("msg_id", m.ID).Int("delivery_attempt", attempt).Msg("gcp: handler returned an error, nacking for redelivery")
+				m.Nack()
+				return
+			}
+			m.Ack()
+		})
+		if err != nil {
+			log.Err(err).Str("subscription", subscriptionName).Msg("gcp: Receive returned an error")
+		}
+	}()
+}
@@ -0,0 +1,14 @@
+package local
+
+import "encore.dev/runtime/config"
+
+// DefaultBus is the process-wide Bus used by pubsub.NewSubscription (and
+// Topic.Publish) when Active reports true.
+var DefaultBus = NewBus()
+
+// Active reports whether pubsub delivery should go through DefaultBus
+// instead of a real broker: inside unit tests, or when running the app
+// locally via `encore run` with no cloud provider configured.
+func Active() bool {
+	return config.Cfg.Static.Testing || config.Cfg.Static.PubsubProvider == config.PubsubProviderLocal
+}
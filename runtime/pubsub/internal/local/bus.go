@@ -0,0 +1,179 @@
+// Package local implements an in-process pubsub broker used when running
+// `encore run` locally and inside tests, where there's no real GCP/AWS/NSQ
+// broker to talk to. Topics and subscriptions are created on first use
+// rather than requiring the static config a deployed app has, since local
+// runs and tests commonly add and remove subscriptions dynamically.
+package local
+
+import (
+	"sync"
+	"time"
+)
+
+// Message is a single message flowing through the Bus.
+type Message struct {
+	ID          string
+	Attrs       map[string]string
+	Data        []byte
+	PublishTime time.Time
+}
+
+// BufferPolicy controls what a subscriber's buffered channel does once it's
+// full.
+type BufferPolicy int
+
+const (
+	// DropOldest discards the oldest buffered message to make room for the
+	// new one. This keeps a slow subscriber from blocking publishers, at the
+	// cost of losing messages - appropriate for local dev, where the usual
+	// failure mode is a subscriber hanging in a debugger.
+	DropOldest BufferPolicy = iota
+
+	// Block makes the publisher wait until the subscriber has room. This
+	// matches at-least-once delivery guarantees more closely, and is what
+	// tests asserting against delivery order generally want.
+	Block
+)
+
+// Bus is an in-process, in-memory pubsub broker. It's safe for concurrent
+// use. The zero value is not usable; create one with NewBus.
+type Bus struct {
+	mu     sync.Mutex
+	topics map[string]*topic
+}
+
+// NewBus creates an empty Bus.
+func NewBus() *Bus {
+	return &Bus{topics: make(map[string]*topic)}
+}
+
+type topic struct {
+	mu   sync.Mutex
+	subs map[string]*subscriber
+}
+
+// Publish fans msg out to every subscriber currently registered on
+// topicName, creating the topic if this is the first message published to
+// it. It never blocks on a slow subscriber when the subscriber's
+// BufferPolicy is DropOldest.
+func (b *Bus) Publish(topicName string, msg Message) {
+	t := b.topic(topicName)
+
+	t.mu.Lock()
+	subs := make([]*subscriber, 0, len(t.subs))
+	for _, s := range t.subs {
+		subs = append(subs, s)
+	}
+	t.mu.Unlock()
+
+	for _, s := range subs {
+		s.deliver(msg)
+	}
+}
+
+// Subscription is a handle to a live subscription created by Subscribe.
+type Subscription struct {
+	sub *subscriber
+}
+
+// Messages returns the channel messages delivered to this subscription are
+// sent on.
+func (s *Subscription) Messages() <-chan Message { return s.sub.ch }
+
+// Subscribe registers a new subscriber named subName on topicName,
+// creating the topic if it doesn't exist yet. bufferSize sets the capacity
+// of the subscriber's channel; policy controls what happens once it's full.
+//
+// Calling Subscribe again with the same topicName/subName replaces the
+// previous subscriber - any subscriber obtained from an earlier call stops
+// receiving new messages.
+func (b *Bus) Subscribe(topicName, subName string, bufferSize int, policy BufferPolicy) *Subscription {
+	t := b.topic(topicName)
+	sub := newSubscriber(bufferSize, policy)
+
+	t.mu.Lock()
+	t.subs[subName] = sub
+	t.mu.Unlock()
+
+	return &Subscription{sub: sub}
+}
+
+// Unsubscribe removes subName from topicName. Messages published
+// afterwards are no longer delivered to it; the subscriber's channel is
+// closed.
+func (b *Bus) Unsubscribe(topicName, subName string) {
+	t := b.topic(topicName)
+
+	t.mu.Lock()
+	sub, ok := t.subs[subName]
+	delete(t.subs, subName)
+	t.mu.Unlock()
+
+	if ok {
+		close(sub.ch)
+	}
+}
+
+func (b *Bus) topic(name string) *topic {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t, ok := b.topics[name]
+	if !ok {
+		t = &topic{subs: make(map[string]*subscriber)}
+		b.topics[name] = t
+	}
+	return t
+}
+
+func (b *Bus) subscriber(topicName, subName string) *subscriber {
+	b.mu.Lock()
+	t, ok := b.topics[topicName]
+	b.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.subs[subName]
+}
+
+// WaitForMessage blocks until a message is delivered to the subscription
+// named subName on topicName, or timeout elapses, returning the most
+// recently delivered one. It's meant for tests: asserting a handler
+// received a specific event without racing against the goroutine that
+// invokes it.
+//
+// If no such subscription exists yet, WaitForMessage keeps checking for it
+// until the timeout, and reports false only if none appears in time -
+// Subscribe and the message's publish can race, so this avoids a test
+// flaking depending on which happens first.
+func (b *Bus) WaitForMessage(topicName, subName string, timeout time.Duration) (Message, bool) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		if sub := b.subscriber(topicName, subName); sub != nil {
+			if msg, ok := sub.waitForMessage(deadline); ok {
+				return msg, true
+			}
+			return Message{}, false
+		}
+		if time.Now().After(deadline) {
+			return Message{}, false
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Drain discards the delivery history and any buffered, undelivered
+// messages for the subscription named subName on topicName, so a later
+// WaitForMessage call only observes messages published after Drain
+// returns.
+func (b *Bus) Drain(topicName, subName string) {
+	sub := b.subscriber(topicName, subName)
+	if sub == nil {
+		return
+	}
+	sub.drain()
+}
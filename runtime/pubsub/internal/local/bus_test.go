@@ -0,0 +1,82 @@
+package local
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBusFanOut(t *testing.T) {
+	b := NewBus()
+	sub1 := b.Subscribe("orders", "sub-1", 4, Block)
+	sub2 := b.Subscribe("orders", "sub-2", 4, Block)
+
+	b.Publish("orders", Message{ID: "1", Data: []byte("hello")})
+
+	for _, sub := range []*Subscription{sub1, sub2} {
+		select {
+		case msg := <-sub.Messages():
+			if string(msg.Data) != "hello" {
+				t.Fatalf("got %q, want %q", msg.Data, "hello")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for fan-out delivery")
+		}
+	}
+}
+
+func TestBusDropOldest(t *testing.T) {
+	b := NewBus()
+	sub := b.Subscribe("orders", "sub-1", 1, DropOldest)
+
+	b.Publish("orders", Message{ID: "1"})
+	b.Publish("orders", Message{ID: "2"}) // should evict message 1
+
+	msg := <-sub.Messages()
+	if msg.ID != "2" {
+		t.Fatalf("got message %q, want %q", msg.ID, "2")
+	}
+}
+
+func TestBusUnsubscribe(t *testing.T) {
+	b := NewBus()
+	sub := b.Subscribe("orders", "sub-1", 1, Block)
+	b.Unsubscribe("orders", "sub-1")
+
+	if _, ok := <-sub.Messages(); ok {
+		t.Fatal("expected channel to be closed after Unsubscribe")
+	}
+}
+
+func TestBusWaitForMessage(t *testing.T) {
+	b := NewBus()
+	b.Subscribe("orders", "sub-1", 4, Block)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.Publish("orders", Message{ID: "1"})
+	}()
+
+	msg, ok := b.WaitForMessage("orders", "sub-1", time.Second)
+	if !ok {
+		t.Fatal("expected a message")
+	}
+	if msg.ID != "1" {
+		t.Fatalf("got message %q, want %q", msg.ID, "1")
+	}
+
+	if _, ok := b.WaitForMessage("orders", "sub-1", 20*time.Millisecond); ok {
+		t.Fatal("expected no further message")
+	}
+}
+
+func TestBusDrain(t *testing.T) {
+	b := NewBus()
+	b.Subscribe("orders", "sub-1", 4, Block)
+	b.Publish("orders", Message{ID: "1"})
+
+	b.Drain("orders", "sub-1")
+
+	if _, ok := b.WaitForMessage("orders", "sub-1", 20*time.Millisecond); ok {
+		t.Fatal("expected Drain to discard the prior message")
+	}
+}
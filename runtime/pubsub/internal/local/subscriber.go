@@ -0,0 +1,111 @@
+package local
+
+import (
+	"sync"
+	"time"
+)
+
+// maxSubscriberLog bounds the delivery history kept per subscriber. Only the
+// most recent entries are kept, since WaitForMessage only ever wants the
+// latest delivery and DefaultBus backs live `encore run` sessions (not just
+// tests) where a topic can see sustained traffic for the life of the
+// session - an unbounded history would leak memory for the whole run.
+const maxSubscriberLog = 64
+
+// subscriber delivers messages to a single subscription's channel according
+// to its BufferPolicy, and keeps a bounded delivery history so
+// Bus.WaitForMessage and Bus.Drain can observe deliveries without racing
+// the goroutine that consumes ch.
+type subscriber struct {
+	ch     chan Message
+	policy BufferPolicy
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	log   []Message
+	total int // count of messages ever delivered, never reset by trimming
+	read  int // total as of the last message returned by waitForMessage or drain
+}
+
+func newSubscriber(bufferSize int, policy BufferPolicy) *subscriber {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+	s := &subscriber{
+		ch:     make(chan Message, bufferSize),
+		policy: policy,
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// deliver hands msg to the subscriber, applying its BufferPolicy if the
+// channel is full, and records it in the delivery history, trimming the
+// oldest entry once the history reaches maxSubscriberLog.
+func (s *subscriber) deliver(msg Message) {
+	s.mu.Lock()
+	if len(s.log) >= maxSubscriberLog {
+		s.log = append(s.log[:0], s.log[1:]...)
+	}
+	s.log = append(s.log, msg)
+	s.total++
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	switch s.policy {
+	case Block:
+		s.ch <- msg
+	case DropOldest:
+		for {
+			select {
+			case s.ch <- msg:
+				return
+			default:
+				select {
+				case <-s.ch:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// waitForMessage blocks until a message has been delivered since the last
+// call to waitForMessage or drain, or deadline passes, returning the most
+// recently delivered message. A second call with no intervening delivery
+// blocks until deadline and reports false, rather than returning the same
+// message again.
+func (s *subscriber) waitForMessage(deadline time.Time) (Message, bool) {
+	timer := time.AfterFunc(time.Until(deadline), func() {
+		s.mu.Lock()
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.total <= s.read {
+		if !time.Now().Before(deadline) {
+			return Message{}, false
+		}
+		s.cond.Wait()
+	}
+	s.read = s.total
+	return s.log[len(s.log)-1], true
+}
+
+func (s *subscriber) drain() {
+	s.mu.Lock()
+	s.log = nil
+	s.read = s.total
+	s.mu.Unlock()
+
+	for {
+		select {
+		case <-s.ch:
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package local
+
+import "testing"
+
+func TestSubscriberLogBounded(t *testing.T) {
+	s := newSubscriber(1, DropOldest)
+
+	for i := 0; i < maxSubscriberLog*4; i++ {
+		s.deliver(Message{ID: string(rune('a' + i%26))})
+	}
+
+	s.mu.Lock()
+	n := len(s.log)
+	s.mu.Unlock()
+
+	if n != maxSubscriberLog {
+		t.Fatalf("got log length %d, want %d", n, maxSubscriberLog)
+	}
+}
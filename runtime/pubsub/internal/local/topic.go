@@ -0,0 +1,72 @@
+package local
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Topic is the topicImpl-shaped handle pubsub.NewTopic stores for a topic
+// backed by DefaultBus. NewSubscription's local.Active() branch talks to
+// DefaultBus directly rather than through this type, but Topic.Publish and
+// DeadLetterPolicy (in package pubsub) both publish through it, the same as
+// they do for the GCP/NSQ/AWS backends.
+type Topic struct {
+	name string
+}
+
+// NewTopic returns a Topic that publishes onto DefaultBus under name.
+func NewTopic(name string) *Topic {
+	return &Topic{name: name}
+}
+
+var nextMessageID uint64
+
+func newMessageID() string {
+	return strconv.FormatUint(atomic.AddUint64(&nextMessageID, 1), 10)
+}
+
+// PublishMessage publishes data and attrs to the bus topic, fanning it out
+// to every subscriber currently registered.
+func (t *Topic) PublishMessage(ctx context.Context, orderingKey string, attrs map[string]string, data []byte) (string, error) {
+	id := newMessageID()
+	DefaultBus.Publish(t.name, Message{
+		ID:          id,
+		Attrs:       attrs,
+		Data:        data,
+		PublishTime: time.Now(),
+	})
+	return id, nil
+}
+
+// PublishDeadLetter republishes data and attrs onto the bus topic, the same
+// as PublishMessage with no ordering key.
+func (t *Topic) PublishDeadLetter(ctx context.Context, attrs map[string]string, data []byte) (string, error) {
+	return t.PublishMessage(ctx, "", attrs, data)
+}
+
+// Subscribe wires handler up to a bus subscription named subscriptionName.
+// filter isn't evaluated here: pubsub.NewSubscription already evaluates it
+// client-side for every backend, and the bus has no broker-side filtering
+// to push it down to. Failed deliveries are logged and dropped - there's no
+// broker to redeliver them.
+func (t *Topic) Subscribe(
+	log *zerolog.Logger,
+	subscriptionName string,
+	maxRetries int,
+	minBackoff, maxBackoff time.Duration,
+	filter string,
+	handler func(ctx context.Context, msgID string, publishTime time.Time, deliveryAttempt int, attrs map[string]string, data []byte) error,
+) {
+	sub := DefaultBus.Subscribe(t.name, subscriptionName, 64, DropOldest)
+	go func() {
+		for msg := range sub.Messages() {
+			if err := handler(context.Background(), msg.ID, msg.PublishTime, 1, msg.Attrs, msg.Data); err != nil {
+				log.Err(err).Str("msg_id", msg.ID).Msg("local pubsub handler returned an error")
+			}
+		}
+	}()
+}
@@ -0,0 +1,98 @@
+// Package nsq backs a pubsub Topic with an NSQ topic/channel pair, for
+// self-hosted deployments that don't use a cloud pubsub provider.
+package nsq
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+	"github.com/rs/zerolog"
+)
+
+// Topic publishes to, and subscribes from, a single NSQ topic.
+type Topic struct {
+	name    string
+	nsqdTCP string
+	prod    *nsq.Producer
+}
+
+// NewTopic creates a Topic backed by the NSQ topic named name, publishing
+// through the local nsqd instance's TCP port.
+func NewTopic(name string) *Topic {
+	prod, err := nsq.NewProducer("127.0.0.1:4150", nsq.NewConfig())
+	if err != nil {
+		panic("nsq: failed to create producer: " + err.Error())
+	}
+	return &Topic{name: name, nsqdTCP: "127.0.0.1:4150", prod: prod}
+}
+
+// envelope is the wire format published to NSQ: NSQ messages carry an
+// opaque body with no attribute side-channel, so attrs travel alongside
+// data instead of as broker-native message attributes.
+type envelope struct {
+	Attrs map[string]string `json:"attrs"`
+	Data  []byte            `json:"data"`
+}
+
+// PublishMessage publishes data and attrs to the topic. NSQ has no concept
+// of ordering keys, so orderingKey is accepted for interface compatibility
+// but otherwise ignored.
+func (t *Topic) PublishMessage(ctx context.Context, orderingKey string, attrs map[string]string, data []byte) (string, error) {
+	body, err := json.Marshal(envelope{Attrs: attrs, Data: data})
+	if err != nil {
+		return "", err
+	}
+	if err := t.prod.Publish(t.name, body); err != nil {
+		return "", err
+	}
+	// NSQ doesn't return a broker-assigned message ID from Publish; the
+	// consumer-side ID (nsq.Message.ID) is only visible to subscribers.
+	return "", nil
+}
+
+// PublishDeadLetter republishes data and attrs verbatim onto the topic.
+func (t *Topic) PublishDeadLetter(ctx context.Context, attrs map[string]string, data []byte) (string, error) {
+	_, err := t.PublishMessage(ctx, "", attrs, data)
+	return "", err
+}
+
+// Subscribe creates an NSQ consumer on subscriptionName (used as the NSQ
+// channel) and invokes handler for every message delivered to it. filter
+// isn't evaluated here - NSQ has no attribute side-channel to filter on
+// broker-side, so pubsub.NewSubscription's client-side filterExpr is what
+// actually applies it.
+func (t *Topic) Subscribe(
+	log *zerolog.Logger,
+	subscriptionName string,
+	maxRetries int,
+	minBackoff, maxBackoff time.Duration,
+	filter string,
+	handler func(ctx context.Context, msgID string, publishTime time.Time, deliveryAttempt int, attrs map[string]string, data []byte) error,
+) {
+	cfg := nsq.NewConfig()
+	cfg.MaxAttempts = uint16(maxRetries)
+	cfg.DefaultRequeueDelay = minBackoff
+	cfg.MaxRequeueDelay = maxBackoff
+
+	consumer, err := nsq.NewConsumer(t.name, subscriptionName, cfg)
+	if err != nil {
+		log.Err(err).Msg("nsq: failed to create consumer")
+		return
+	}
+
+	consumer.AddHandler(nsq.HandlerFunc(func(m *nsq.Message) error {
+		var env envelope
+		if err := json.Unmarshal(m.Body, &env); err != nil {
+			log.Err(err).Msg("nsq: failed to decode message envelope")
+			return err
+		}
+		return handler(context.Background(), strconv.Itoa(int(m.Timestamp)), time.Unix(0, m.Timestamp), int(m.Attempts), env.Attrs, env.Data)
+	}))
+
+	if err := consumer.ConnectToNSQD(t.nsqdTCP); err != nil {
+		log.Err(err).Msg("nsq: failed to connect to nsqd")
+	}
+}
@@ -0,0 +1,112 @@
+package pubsub
+
+import "sync"
+
+// orderingKeyAttr is the reserved message attribute that carries a message's
+// ordering key, set by Topic.Publish when WithOrderingKey is passed and
+// read back out by NewSubscription when
+// SubscriptionConfig.AckOrdering is enabled.
+const orderingKeyAttr = "encore_ordering_key"
+
+// orderingScheduler serializes handler invocations that share an ordering
+// key, while allowing messages with different keys (or no key) to be handled
+// concurrently up to a bounded worker pool. It backs
+// SubscriptionConfig.AckOrdering.
+//
+// Messages without an ordering key are only subject to the overall
+// concurrency bound. GCP Pub/Sub (and the other broker backends, once they
+// support ordering) won't redeliver the next message for a key until the
+// current one is acknowledged, so a failed delivery naturally pauses that
+// key until its retry succeeds; the scheduler's job is just to guarantee we
+// never run two handler invocations for the same key concurrently on this
+// node, e.g. during a broker-side redelivery race.
+//
+// Per-key state is reference-counted: a key's queue exists only while at
+// least one call to run is waiting on or holding it, and is removed the
+// moment the last waiter finishes. This keeps memory bounded by the number
+// of keys with messages in flight right now, rather than by the number of
+// distinct keys ever seen - the latter would grow without bound for
+// high-cardinality keys (e.g. per-order IDs) over the life of a
+// long-running subscriber.
+type orderingScheduler struct {
+	sem chan struct{} // bounds total concurrent handler invocations across all keys
+
+	mu    sync.Mutex
+	queue map[string]*keyQueue // one entry per ordering key currently in use
+}
+
+// keyQueue serializes run calls for a single ordering key in FIFO order:
+// ch is a 1-buffered channel acting as a mutex that's handed off in the
+// order goroutines arrive, rather than in whatever order Go's runtime
+// happens to wake blocked Lock() callers - which is the respect in which a
+// plain sync.Mutex doesn't actually guarantee delivery order under
+// contention.
+type keyQueue struct {
+	ch   chan struct{}
+	refs int // number of in-flight run calls waiting on or holding this key
+}
+
+// newOrderingScheduler creates a scheduler that allows at most maxConcurrency
+// handler invocations to run at once, across all ordering keys combined.
+func newOrderingScheduler(maxConcurrency int) *orderingScheduler {
+	if maxConcurrency <= 0 {
+		maxConcurrency = 100
+	}
+	return &orderingScheduler{
+		sem:   make(chan struct{}, maxConcurrency),
+		queue: make(map[string]*keyQueue),
+	}
+}
+
+// run calls fn, first acquiring the per-key queue if orderingKey is
+// non-empty, then a worker pool slot. It blocks until fn can start, providing
+// back-pressure to the caller once the worker pool is saturated or a prior
+// message for the same key is still in flight. Concurrent calls for the
+// same key run fn in the order they called run.
+func (s *orderingScheduler) run(orderingKey string, fn func() error) error {
+	if orderingKey == "" {
+		s.sem <- struct{}{}
+		defer func() { <-s.sem }()
+		return fn()
+	}
+
+	q := s.acquireKey(orderingKey)
+	<-q.ch
+	defer s.releaseKey(orderingKey, q)
+
+	s.sem <- struct{}{}
+	defer func() { <-s.sem }()
+
+	return fn()
+}
+
+// acquireKey returns the queue for key, creating it (pre-unlocked, since
+// the caller is its first and only holder so far) if this is the first
+// waiter, and registers the caller as a waiter on it.
+func (s *orderingScheduler) acquireKey(key string) *keyQueue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, ok := s.queue[key]
+	if !ok {
+		q = &keyQueue{ch: make(chan struct{}, 1)}
+		q.ch <- struct{}{}
+		s.queue[key] = q
+	}
+	q.refs++
+	return q
+}
+
+// releaseKey hands q's lock to the next waiter (if run was holding it) and
+// deregisters the caller; if no waiters remain, q is removed from the
+// scheduler entirely so idle keys don't hold memory indefinitely.
+func (s *orderingScheduler) releaseKey(key string, q *keyQueue) {
+	q.ch <- struct{}{}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q.refs--
+	if q.refs == 0 {
+		delete(s.queue, key)
+	}
+}
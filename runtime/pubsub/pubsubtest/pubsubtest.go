@@ -0,0 +1,34 @@
+// Package pubsubtest provides helpers for asserting on pubsub activity in
+// tests, backed by the in-process event bus NewSubscription uses when
+// running inside a test binary.
+package pubsubtest
+
+import (
+	"time"
+
+	"encore.dev/pubsub/internal/local"
+)
+
+// WaitForMessage blocks until a message is delivered to subscription on
+// topic, or timeout elapses, returning the most recently delivered message
+// and true. If no message arrives in time, it returns the zero value and
+// false.
+//
+// Use this instead of a sleep-and-check loop to assert that a subscription
+// handler received a specific event, without racing against the goroutine
+// that invokes the handler.
+func WaitForMessage(topic, subscription string, timeout time.Duration) (attrs map[string]string, data []byte, ok bool) {
+	msg, ok := local.DefaultBus.WaitForMessage(topic, subscription, timeout)
+	if !ok {
+		return nil, nil, false
+	}
+	return msg.Attrs, msg.Data, true
+}
+
+// Drain discards any messages already delivered to subscription on topic,
+// so a later WaitForMessage call only observes messages published after
+// Drain returns. Call this between test cases that reuse the same
+// topic/subscription to avoid observing a previous case's message.
+func Drain(topic, subscription string) {
+	local.DefaultBus.Drain(topic, subscription)
+}
@@ -5,6 +5,9 @@ import (
 	"time"
 
 	"encore.dev/beta/errs"
+	"encore.dev/pubsub/eventreg"
+	"encore.dev/pubsub/internal/filter"
+	"encore.dev/pubsub/internal/local"
 	"encore.dev/pubsub/internal/utils"
 	"encore.dev/runtime"
 	"encore.dev/runtime/config"
@@ -29,25 +32,25 @@ type Subscription[T any] struct{}
 //
 // Example:
 //
-//     import "encore.dev/pubsub"
+//	import "encore.dev/pubsub"
 //
-//     type MyEvent struct {
-//       Foo string
-//     }
+//	type MyEvent struct {
+//	  Foo string
+//	}
 //
-//     var MyTopic = pubsub.NewTopic[*MyEvent]("my-topic", pubsub.TopicConfig{
-//       DeliveryGuarantee: pubsub.AtLeastOnce,
-//     })
+//	var MyTopic = pubsub.NewTopic[*MyEvent]("my-topic", pubsub.TopicConfig{
+//	  DeliveryGuarantee: pubsub.AtLeastOnce,
+//	})
 //
-//     var Subscription = pubsub.NewSubscription(MyTopic, "my-subscription", pubsub.SubscriptionConfig[*MyEvent]{
-//       Handler:     HandleEvent,
-//       RetryPolicy: &pubsub.RetryPolicy { MaxRetries: 10 },
-//     })
+//	var Subscription = pubsub.NewSubscription(MyTopic, "my-subscription", pubsub.SubscriptionConfig[*MyEvent]{
+//	  Handler:     HandleEvent,
+//	  RetryPolicy: &pubsub.RetryPolicy { MaxRetries: 10 },
+//	})
 //
-//     func HandleEvent(ctx context.Context, event *MyEvent) error {
-//       rlog.Info("received foo")
-//       return nil
-//     }
+//	func HandleEvent(ctx context.Context, event *MyEvent) error {
+//	  rlog.Info("received foo")
+//	  return nil
+//	}
 func NewSubscription[T any](topic *Topic[T], name string, subscriptionCfg SubscriptionConfig[T]) *Subscription[T] {
 	if topic.topicCfg == nil || topic.topic == nil {
 		panic("pubsub topic was not created using pubsub.NewTopic")
@@ -68,6 +71,26 @@ func NewSubscription[T any](topic *Topic[T], name string, subscriptionCfg Subscr
 	subscriptionCfg.RetryPolicy.MinBackoff = utils.WithDefaultValue(subscriptionCfg.RetryPolicy.MinBackoff, 10*time.Second)
 	subscriptionCfg.RetryPolicy.MaxBackoff = utils.WithDefaultValue(subscriptionCfg.RetryPolicy.MaxBackoff, 10*time.Minute)
 
+	if subscriptionCfg.DeadLetterPolicy != nil {
+		if subscriptionCfg.DeadLetterPolicy.DeadLetterTopic == nil {
+			panic("DeadLetterPolicy.DeadLetterTopic cannot be nil")
+		}
+		if subscriptionCfg.DeadLetterPolicy.MaxDeliveryAttempts < 2 {
+			panic("DeadLetterPolicy.MaxDeliveryAttempts must be at least 2")
+		}
+	}
+
+	// Parse the filter expression once, at registration time, so evaluating it
+	// against incoming messages is just a tree walk.
+	var filterExpr filter.Expr
+	if subscriptionCfg.Filter != "" {
+		var err error
+		filterExpr, err = filter.Parse(subscriptionCfg.Filter)
+		if err != nil {
+			panic("invalid pubsub subscription filter: " + err.Error())
+		}
+	}
+
 	subscription, staticCfg := topic.getSubscriptionConfig(name)
 	panicCatchWrapper := func(ctx context.Context, msg T) (err error) {
 		defer func() {
@@ -85,20 +108,78 @@ func NewSubscription[T any](topic *Topic[T], name string, subscriptionCfg Subscr
 		Str("subscription", name).
 		Logger()
 
-	// Subscribe to the topic
-	topic.topic.Subscribe(&log, subscriptionCfg.RetryPolicy, subscription, func(ctx context.Context, msgID string, publishTime time.Time, deliveryAttempt int, attrs map[string]string, data []byte) (err error) {
+	// scheduler serializes handler invocations per ordering key when
+	// AckOrdering is enabled; nil otherwise, in which case deliver runs
+	// directly with no extra serialization.
+	var scheduler *orderingScheduler
+	if subscriptionCfg.AckOrdering {
+		scheduler = newOrderingScheduler(100)
+	}
+
+	// idempotencyEvent backs the deduplication check below. It's looked up
+	// once at registration time, the same as filterExpr above, rather than
+	// by name on every delivery.
+	var idempotencyEvent *eventreg.Event[T]
+	if subscriptionCfg.Idempotency != "" {
+		var ok bool
+		idempotencyEvent, ok = eventreg.Lookup[T](subscriptionCfg.Idempotency)
+		if !ok {
+			panic("pubsub: no eventreg.Event registered for idempotency name " + subscriptionCfg.Idempotency)
+		}
+	}
+
+	idempotencyStore := subscriptionCfg.IdempotencyStore
+	if idempotencyStore == nil {
+		idempotencyStore = eventreg.DefaultStore()
+	}
+
+	deliver := func(ctx context.Context, msgID string, publishTime time.Time, deliveryAttempt int, attrs map[string]string, data []byte) (err error) {
 		if !config.Cfg.Static.Testing {
 			// Under test we're already inside an operation
 			runtime.BeginOperation()
 			defer runtime.FinishOperation()
 		}
 
+		if filterExpr != nil && !filterExpr.Eval(attrs) {
+			log.Debug().Str("msg_id", msgID).Msg("message did not match subscription filter, ack'ing without a request span")
+			return nil
+		}
+
 		msg, err := utils.UnmarshalMessage[T](attrs, data)
 		if err != nil {
 			log.Err(err).Str("msg_id", msgID).Int("delivery_attempt", deliveryAttempt).Msg("failed to unmarshal message")
 			return errs.B().Code(errs.Internal).Cause(err).Msg("failed to unmarshal message").Err()
 		}
 
+		if idempotencyEvent != nil {
+			key := idempotencyEvent.Name + ":" + idempotencyEvent.IdempotencyKey(msg)
+			claimed, markErr := idempotencyStore.MarkPending(ctx, key, idempotencyEvent.TTL)
+			if markErr != nil {
+				log.Err(markErr).Str("msg_id", msgID).Str("idempotency_key", key).Msg("failed to check idempotency store")
+				return errs.B().Code(errs.Internal).Cause(markErr).Msg("failed to check idempotency store").Err()
+			}
+			if !claimed {
+				log.Debug().Str("msg_id", msgID).Str("idempotency_key", key).Msg("duplicate event, ack'ing without invoking handler")
+				return nil
+			}
+
+			// The claim above must be undone on failure so a redelivery gets
+			// a real attempt, and confirmed on success so later redeliveries
+			// are recognized as duplicates - see eventreg.Store's doc
+			// comment for why this can't just be a single check-and-mark.
+			defer func() {
+				if err != nil {
+					if releaseErr := idempotencyStore.Release(ctx, key); releaseErr != nil {
+						log.Err(releaseErr).Str("msg_id", msgID).Str("idempotency_key", key).Msg("failed to release idempotency key after handler failure")
+					}
+					return
+				}
+				if commitErr := idempotencyStore.Commit(ctx, key, idempotencyEvent.TTL); commitErr != nil {
+					log.Err(commitErr).Str("msg_id", msgID).Str("idempotency_key", key).Msg("failed to commit idempotency key")
+				}
+			}()
+		}
+
 		// Start the request tracing span
 		err = runtime.BeginRequest(ctx, runtime.RequestData{
 			Type:    runtime.PubSubMessage,
@@ -120,8 +201,51 @@ func NewSubscription[T any](topic *Topic[T], name string, subscriptionCfg Subscr
 
 		err = panicCatchWrapper(ctx, msg)
 		runtime.FinishRequest(nil, err)
+
+		if err != nil && subscriptionCfg.DeadLetterPolicy.exhausted(deliveryAttempt) {
+			dlqErr := deadLetter(ctx, subscriptionCfg.DeadLetterPolicy, topic.topicCfg.EncoreName, subscription.EncoreName, deliveryAttempt, err, attrs, data)
+			if dlqErr != nil {
+				log.Err(dlqErr).Str("msg_id", msgID).Int("delivery_attempt", deliveryAttempt).Msg("failed to publish message to dead letter topic")
+				return err
+			}
+			log.Warn().Str("msg_id", msgID).Int("delivery_attempt", deliveryAttempt).Err(err).Msg("delivery attempts exhausted, routed message to dead letter topic")
+			return nil
+		}
+
 		return err
-	})
+	}
+
+	handle := func(ctx context.Context, msgID string, publishTime time.Time, deliveryAttempt int, attrs map[string]string, data []byte) error {
+		if scheduler == nil {
+			return deliver(ctx, msgID, publishTime, deliveryAttempt, attrs, data)
+		}
+		return scheduler.run(attrs[orderingKeyAttr], func() error {
+			return deliver(ctx, msgID, publishTime, deliveryAttempt, attrs, data)
+		})
+	}
+
+	if local.Active() {
+		// Running against the in-process event bus (encore run locally, or a
+		// test binary): there's no static subscription config and no real
+		// broker to redeliver on error, so a failing message is just logged
+		// rather than retried. See pubsubtest for asserting on what a
+		// subscription received.
+		sub := local.DefaultBus.Subscribe(topic.topicCfg.EncoreName, name, 64, local.DropOldest)
+		go func() {
+			for msg := range sub.Messages() {
+				if err := handle(context.Background(), msg.ID, msg.PublishTime, 1, msg.Attrs, msg.Data); err != nil {
+					log.Err(err).Str("msg_id", msg.ID).Msg("local pubsub handler returned an error")
+				}
+			}
+		}()
+	} else {
+		// Subscribe to the topic. subscriptionCfg.Filter is passed through so
+		// that backends which support native filtering (e.g. GCP) can rely on
+		// it having already been applied at provisioning time; filterExpr
+		// above is evaluated client-side regardless, so backends that can't
+		// push it down (NSQ, AWS) still get correct filtering.
+		topic.topic.Subscribe(&log, subscription.EncoreName, subscriptionCfg.RetryPolicy.MaxRetries, subscriptionCfg.RetryPolicy.MinBackoff, subscriptionCfg.RetryPolicy.MaxBackoff, subscriptionCfg.Filter, handle)
+	}
 
 	if !config.Cfg.Static.Testing {
 		// Log the subscription registration - unless we're in unit tests
@@ -142,6 +266,15 @@ func (t *Topic[T]) getSubscriptionConfig(name string) (*config.PubsubSubscriptio
 	// Fetch the subscription configuration
 	subscription, ok := t.topicCfg.Subscriptions[name]
 	if !ok {
+		if local.Active() {
+			// Running against the in-process event bus: subscriptions aren't
+			// known ahead of time from a deployed static config, since local
+			// runs and tests commonly add them dynamically. Register one
+			// rather than failing hard.
+			return &config.PubsubSubscription{EncoreName: name}, &config.StaticPubsubSubscription{
+				Service: &config.Service{Name: t.topicCfg.EncoreName},
+			}
+		}
 		runtime.Logger().Fatal().Msgf("unregistered/unknown subscription on topic %s: %s", t.topicCfg.EncoreName, name)
 	}
 
@@ -0,0 +1,126 @@
+package pubsub
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"encore.dev/pubsub/internal/aws"
+	"encore.dev/pubsub/internal/gcp"
+	"encore.dev/pubsub/internal/local"
+	"encore.dev/pubsub/internal/nsq"
+	"encore.dev/pubsub/internal/utils"
+	"encore.dev/runtime"
+	"encore.dev/runtime/config"
+)
+
+// TopicConfig configures a Topic.
+type TopicConfig struct {
+	// DeliveryGuarantee is the delivery guarantee for the topic.
+	DeliveryGuarantee DeliveryGuarantee
+}
+
+// topicImpl is implemented by each pubsub broker backend: GCP, AWS, NSQ, and
+// the in-process backend used by `encore run` and tests. NewTopic picks the
+// implementation to use based on the app's configured pubsub provider.
+type topicImpl interface {
+	// PublishMessage publishes data with attrs to the topic and returns the
+	// broker-assigned message ID. orderingKey is empty unless the publisher
+	// passed one via WithOrderingKey.
+	PublishMessage(ctx context.Context, orderingKey string, attrs map[string]string, data []byte) (id string, err error)
+
+	// PublishDeadLetter republishes data and attrs verbatim, with no
+	// ordering key. It backs DeadLetterPolicy.
+	PublishDeadLetter(ctx context.Context, attrs map[string]string, data []byte) (id string, err error)
+
+	// Subscribe registers handler to be called for every message delivered
+	// to subscriptionName. filter, if non-empty, is a Pub/Sub-style
+	// attribute filter expression; backends that support native filtering
+	// should rely on it having already been applied at provisioning time
+	// rather than re-evaluating it here.
+	Subscribe(
+		log *zerolog.Logger,
+		subscriptionName string,
+		maxRetries int,
+		minBackoff, maxBackoff time.Duration,
+		filter string,
+		handler func(ctx context.Context, msgID string, publishTime time.Time, deliveryAttempt int, attrs map[string]string, data []byte) error,
+	)
+}
+
+// Topic represents a pubsub topic of messages of type T.
+type Topic[T any] struct {
+	topicCfg *config.PubsubTopic
+	topic    topicImpl
+}
+
+// NewTopic declares a new pubsub topic, used to publish messages of type T.
+//
+// A call to NewTopic can only be made when declaring a package level
+// variable. Any calls to this function made outside a package level
+// variable declaration will result in a compiler error.
+//
+// Example:
+//
+//	var MyTopic = pubsub.NewTopic[*MyEvent]("my-topic", pubsub.TopicConfig{
+//	  DeliveryGuarantee: pubsub.AtLeastOnce,
+//	})
+func NewTopic[T any](name string, topicCfg TopicConfig) *Topic[T] {
+	if name == "" {
+		panic("pubsub topic name cannot be empty")
+	}
+
+	t := &Topic[T]{topicCfg: &config.PubsubTopic{EncoreName: name}}
+
+	switch {
+	case config.Cfg.Static.Testing, local.Active():
+		t.topic = local.NewTopic(name)
+	case config.Cfg.Static.PubsubProvider == config.PubsubProviderGCP:
+		t.topic = gcp.NewTopic(name)
+	case config.Cfg.Static.PubsubProvider == config.PubsubProviderNSQ:
+		t.topic = nsq.NewTopic(name)
+	case config.Cfg.Static.PubsubProvider == config.PubsubProviderAWS:
+		t.topic = aws.NewTopic(name)
+	default:
+		runtime.Logger().Fatal().Msgf("pubsub topic %s: no pubsub provider configured", name)
+	}
+
+	return t
+}
+
+// PublishOption configures an individual Publish call. See WithOrderingKey.
+type PublishOption func(*publishOptions)
+
+type publishOptions struct {
+	orderingKey string
+}
+
+// WithOrderingKey sets the message's ordering key. Subscriptions with
+// AckOrdering enabled invoke their handler for messages sharing a key in
+// the order they were published; messages with no key, or with different
+// keys, are not ordered relative to each other.
+func WithOrderingKey(key string) PublishOption {
+	return func(o *publishOptions) { o.orderingKey = key }
+}
+
+// Publish publishes msg to the topic, returning the broker-assigned message
+// ID once the broker has durably accepted it.
+func (t *Topic[T]) Publish(ctx context.Context, msg T, opts ...PublishOption) (id string, err error) {
+	var o publishOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	attrs, data, err := utils.MarshalMessage(msg)
+	if err != nil {
+		return "", err
+	}
+	if o.orderingKey != "" {
+		if attrs == nil {
+			attrs = make(map[string]string, 1)
+		}
+		attrs[orderingKeyAttr] = o.orderingKey
+	}
+	return t.topic.PublishMessage(ctx, o.orderingKey, attrs, data)
+}